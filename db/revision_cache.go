@@ -1,7 +1,7 @@
 package db
 
 import (
-	"container/list"
+	"runtime"
 	"sync"
 	"time"
 
@@ -13,14 +13,58 @@ import (
 // Number of recently-accessed doc revisions to cache in RAM
 var KDefaultRevisionCacheCapacity uint32 = 5000
 
-// An LRU cache of document revision bodies, together with their channel access.
+// Default number of shards a RevisionCache is split across, when not otherwise specified.
+const DefaultRevisionCacheShardCount = 16
+
+// Minimum interval between background TTL sweeps, regardless of how short the configured TTL is.
+const minRevCacheSweepInterval = time.Second
+
+// Names of the expvar counters emitted by the TTL machinery.  These aren't part of the base.StatKey*
+// set because they're only present when a cache is configured with a TTL.
+const (
+	statRevCacheExpirations  = "rev_cache_expirations"
+	statRevCacheTTLEvictions = "rev_cache_ttl_evictions"
+	statRevCacheL2Hits       = "rev_cache_l2_hits"
+	statRevCacheL2Misses     = "rev_cache_l2_misses"
+	statRevCacheL2Bytes      = "rev_cache_l2_bytes"
+
+	statRevCacheDeltaComputeAvoided = "rev_cache_delta_compute_avoided"
+	statRevCacheDeltaComputeServed  = "rev_cache_delta_compute_served"
+)
+
+// Default number of deltas retained per cached revision, and the default number of times a given
+// (from,to) delta pair must be requested before it's considered worth computing and storing.
+const (
+	DefaultMaxDeltasPerRevision = 4
+	DefaultMinDeltaRequests     = 2
+)
+
+// An LRU cache of document revision bodies, together with their channel access.  The cache is
+// split into a number of independently-locked shards so that concurrent access to different
+// documents doesn't contend on a single mutex.
 type RevisionCache struct {
-	cache      map[IDAndRev]*list.Element // Fast lookup of list element by doc/rev ID
-	lruList    *list.List                 // List ordered by most recent access (Front is newest)
-	capacity   uint32                     // Max number of revisions to cache
-	loaderFunc RevisionCacheLoaderFunc    // Function which does actual loading of something from rev cache
-	lock       sync.Mutex                 // For thread-safety
-	statsCache *expvar.Map                // Per-db stats related to cache
+	shards           []*revisionCacheShard   // Independently-locked LRU shards
+	shardCount       uint32                  // len(shards), cached to avoid a len() + conversion on every lookup
+	capacity         uint32                  // Max number of revisions to cache, summed across all shards
+	loaderFunc       RevisionCacheLoaderFunc // Function which does actual loading of something from rev cache
+	statsCache       *expvar.Map             // Per-db stats related to cache
+	ttl              time.Duration           // Optional per-entry expiry; zero means entries never expire
+	sweepInterval    time.Duration           // How often the background sweeper runs when ttl is set
+	stopSweep        chan struct{}           // Closed by Close() to stop the background sweeper
+	stopOnce         sync.Once               // Guards against double-close of stopSweep
+	l2               RevisionCacheL2         // Optional on-disk overflow tier, consulted on L1 miss
+	maxDeltas        int                     // Max number of deltas retained per cached revision
+	minDeltaRequests int                     // Number of requests for a given (from,to) pair before it's worth computing
+}
+
+// One shard of a RevisionCache: an independent cache with its own map, eviction policy and lock.  A
+// document's shard is chosen by hashing its doc/rev ID, so unrelated documents essentially never
+// contend.
+type revisionCacheShard struct {
+	cache    map[IDAndRev]*revCacheValue // Fast lookup of cache entry by doc/rev ID
+	policy   EvictionPolicy              // Decides what's resident and what gets evicted next
+	capacity uint32                      // Max number of revisions to cache in this shard
+	lock     sync.Mutex                  // For thread-safety within this shard
 }
 
 // Revision information as returned by the rev cache
@@ -31,46 +75,272 @@ type DocumentRevision struct {
 	Channels    base.Set
 	Expiry      *time.Time
 	Attachments AttachmentsMeta
-	Delta       *RevCacheDelta
+
+	// Delta is kept for source compatibility with callers predating per-target delta chains
+	// (see RevCacheDelta) and is always nil here - a cached revision can now serve deltas to
+	// multiple target revisions, so there's no single delta to populate this with. Use
+	// RevisionCache.GetDelta(docID, RevID, toRev) to look up the delta to a specific target.
+	Delta *RevCacheDelta
 }
 
 // Callback function signature for loading something from the rev cache
 type RevisionCacheLoaderFunc func(id IDAndRev) (body Body, history Revisions, channels base.Set, attachments AttachmentsMeta, expiry *time.Time, err error)
 
-// The cache payload data. Stored as the Value of a list Element.
+// The cache payload data, keyed by doc/rev ID in a revisionCacheShard's cache map. Eviction
+// ordering is tracked separately, by the shard's EvictionPolicy - lruEvictionPolicy threads its
+// list directly through lruPrev/lruNext below, so the default policy can reorder and evict without
+// a second map lookup or allocation per touch; LFU/TinyLFU use their own value-keyed structures
+// instead and leave these two fields unused.
 type revCacheValue struct {
-	key         IDAndRev        // doc/rev IDs
-	body        Body            // Revision body (a pristine shallow copy)
-	history     Revisions       // Rev history encoded like a "_revisions" property
-	channels    base.Set        // Set of channels that have access
-	expiry      *time.Time      // Document expiry
-	attachments AttachmentsMeta // Document _attachments property
-	delta       *RevCacheDelta  // Available delta *from* this revision
-	err         error           // Error from loaderFunc if it failed
-	lock        sync.Mutex      // Synchronizes access to this struct
+	key               IDAndRev                  // doc/rev IDs
+	body              Body                      // Revision body (a pristine shallow copy)
+	history           Revisions                 // Rev history encoded like a "_revisions" property
+	channels          base.Set                  // Set of channels that have access
+	expiry            *time.Time                // Document expiry
+	attachments       AttachmentsMeta           // Document _attachments property
+	deltas            map[string]*RevCacheDelta // Available deltas *from* this revision, keyed by ToRevID
+	deltaOrder        []string                  // ToRevIDs in insertion order, oldest first, for bounding len(deltas)
+	deltaRequestCount map[string]int            // ToRevID -> number of times a delta to that rev has been requested
+	err               error                     // Error from loaderFunc if it failed
+	lock              sync.Mutex                // Synchronizes access to this struct
+	insertedAt        time.Time                 // Time this value was created; used to drive TTL expiration
+	lruPrev, lruNext  *revCacheValue            // Intrusive LRU list links, used only by lruEvictionPolicy
 }
 
+// RevCacheDelta is a single delta computed between two revisions, cached on the source revision.
 type RevCacheDelta struct {
 	ToRevID    string
 	DeltaBytes []byte
 }
 
-// Creates a revision cache with the given capacity and an optional loader function.
+// Creates a revision cache with the given capacity and an optional loader function.  The cache is
+// sharded DefaultRevisionCacheShardCount ways and evicts via plain LRU; use NewShardedRevisionCache
+// or NewRevisionCacheWithPolicy to configure the shard count or eviction policy explicitly.
 func NewRevisionCache(capacity uint32, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map) *RevisionCache {
+	return NewShardedRevisionCache(capacity, DefaultRevisionCacheShardCount, loaderFunc, statsCache)
+}
+
+// Creates a revision cache with the given total capacity, split evenly across shardCount
+// independently-locked shards (any remainder from the division is placed in the first shard).
+// Sharding avoids a single global mutex becoming a bottleneck on databases with high concurrent
+// replicator fan-out. A document's shard is selected by hashing its doc/rev ID, so the exported
+// behavior of the cache - what's resident, eviction order within the cache as a whole - is
+// unaffected by shardCount; only lock contention changes. Evicts via plain LRU; use
+// NewRevisionCacheWithPolicy for LFU/TinyLFU.
+func NewShardedRevisionCache(capacity uint32, shardCount uint32, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map) *RevisionCache {
+	return newRevisionCache(capacity, shardCount, NewLRUEvictionPolicy, loaderFunc, statsCache)
+}
+
+// Creates a revision cache whose eviction behavior is driven by policyFactory, which is called once
+// per shard to build that shard's EvictionPolicy sized to its share of capacity.  Revision-access
+// patterns are often heavy-tailed - a few hot docs dominate - and plain LRU can evict them during
+// scan-like bursts such as an initial replicator pull; TinyLFU in particular is designed to resist
+// that "scan pollution".
+func NewRevisionCacheWithPolicy(capacity uint32, policyFactory EvictionPolicyFactory, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map) *RevisionCache {
+	return newRevisionCache(capacity, DefaultRevisionCacheShardCount, policyFactory, loaderFunc, statsCache)
+}
+
+func newRevisionCache(capacity uint32, shardCount uint32, policyFactory EvictionPolicyFactory, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map) *RevisionCache {
 
 	if capacity == 0 {
 		capacity = KDefaultRevisionCacheCapacity
 	}
+	if shardCount == 0 {
+		shardCount = DefaultRevisionCacheShardCount
+	}
+	if policyFactory == nil {
+		policyFactory = NewLRUEvictionPolicy
+	}
+
+	perShardCapacity := capacity / shardCount
+	remainder := capacity % shardCount
+
+	shards := make([]*revisionCacheShard, shardCount)
+	for i := uint32(0); i < shardCount; i++ {
+		shardCapacity := perShardCapacity
+		if i == 0 {
+			shardCapacity += remainder
+		}
+		shards[i] = newRevisionCacheShard(shardCapacity, policyFactory)
+	}
 
 	return &RevisionCache{
-		cache:      map[IDAndRev]*list.Element{},
-		lruList:    list.New(),
-		capacity:   capacity,
-		loaderFunc: loaderFunc,
-		statsCache: statsCache,
+		shards:           shards,
+		shardCount:       shardCount,
+		capacity:         capacity,
+		loaderFunc:       loaderFunc,
+		statsCache:       statsCache,
+		maxDeltas:        DefaultMaxDeltasPerRevision,
+		minDeltaRequests: 1, // store a delta the first time it's provided, unless a policy says otherwise
+	}
+}
+
+// Creates a revision cache that only stores a computed delta after the same (from,to) revision
+// pair has been requested minDeltaRequests times, and retains at most maxDeltas deltas per cached
+// revision.  This avoids spending CPU computing and storing deltas for one-shot replications, while
+// still letting a single base revision serve deltas to multiple downstream revisions.  A
+// minDeltaRequests of 1 reproduces the default "always store" behavior.
+func NewRevisionCacheWithDeltaPolicy(capacity uint32, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map, maxDeltas int, minDeltaRequests int) *RevisionCache {
+	rc := NewShardedRevisionCache(capacity, DefaultRevisionCacheShardCount, loaderFunc, statsCache)
+	if maxDeltas <= 0 {
+		maxDeltas = DefaultMaxDeltasPerRevision
+	}
+	if minDeltaRequests <= 0 {
+		minDeltaRequests = DefaultMinDeltaRequests
+	}
+	rc.maxDeltas = maxDeltas
+	rc.minDeltaRequests = minDeltaRequests
+	return rc
+}
+
+func newRevisionCacheShard(capacity uint32, policyFactory EvictionPolicyFactory) *revisionCacheShard {
+	return &revisionCacheShard{
+		cache:    map[IDAndRev]*revCacheValue{},
+		policy:   policyFactory(capacity),
+		capacity: capacity,
 	}
 }
 
+// shardForKey returns the shard responsible for the given doc/rev ID.
+func (rc *RevisionCache) shardForKey(key IDAndRev) *revisionCacheShard {
+	return rc.shards[fnv32(key.DocID+key.RevID)%rc.shardCount]
+}
+
+// fnv32 is a small, fast, non-cryptographic hash used purely to distribute keys across shards.
+func fnv32(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash *= prime32
+		hash ^= uint32(s[i])
+	}
+	return hash
+}
+
+// Creates a revision cache backed by the given L2 tier, so that entries evicted from the in-memory
+// LRU can still be served from local disk instead of round-tripping to the bucket.  Useful for
+// trading a bit of local disk for dramatically fewer bucket reads during bulk replication catch-up.
+func NewRevisionCacheWithL2(capacity uint32, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map, l2 RevisionCacheL2) *RevisionCache {
+	rc := NewShardedRevisionCache(capacity, DefaultRevisionCacheShardCount, loaderFunc, statsCache)
+	rc.l2 = l2
+	return rc
+}
+
+// Creates a revision cache with the given capacity and loader function, where cached entries also
+// expire after ttl even if they're still within the LRU's capacity.  This is useful when cached
+// channel/access metadata can go stale relative to the underlying bucket document (e.g. a principal's
+// access grants changed), since plain LRU eviction only reacts to memory pressure, not staleness.
+//
+// A ttl <= 0 disables expiration entirely (no background sweeper is started; this is equivalent to
+// NewShardedRevisionCache).
+//
+// A background goroutine sweeps expired entries out of the cache periodically; call Close() to stop
+// it once the cache is no longer needed. A finalizer is also registered as a safety net in case the
+// caller forgets - but only once Close() has run does rc stop being reachable from the sweeper, so
+// the finalizer is what actually reclaims the goroutine in that case, not the other way around. See
+// revisionCacheSweeper for why the sweeper is a separate type rather than a method on *RevisionCache.
+func NewRevisionCacheWithTTL(capacity uint32, ttl time.Duration, loaderFunc RevisionCacheLoaderFunc, statsCache *expvar.Map) *RevisionCache {
+
+	rc := NewShardedRevisionCache(capacity, DefaultRevisionCacheShardCount, loaderFunc, statsCache)
+	if ttl <= 0 {
+		return rc
+	}
+	rc.ttl = ttl
+
+	rc.sweepInterval = ttl / 10
+	if rc.sweepInterval < minRevCacheSweepInterval {
+		rc.sweepInterval = minRevCacheSweepInterval
+	}
+	rc.stopSweep = make(chan struct{})
+
+	sweeper := &revisionCacheSweeper{
+		shards:     rc.shards,
+		ttl:        rc.ttl,
+		statsCache: rc.statsCache,
+		stopSweep:  rc.stopSweep,
+	}
+	go sweeper.run(rc.sweepInterval)
+	runtime.SetFinalizer(rc, (*RevisionCache).Close)
+
+	return rc
+}
+
+// Close stops the background TTL sweeper, if one is running.  Safe to call multiple times, and safe
+// to call on a cache that was never created with a TTL.
+func (rc *RevisionCache) Close() {
+	rc.stopOnce.Do(func() {
+		if rc.stopSweep != nil {
+			close(rc.stopSweep)
+		}
+	})
+}
+
+// revisionCacheSweeper holds the state the background TTL sweep goroutine needs to run
+// independently of the *RevisionCache that created it. If the goroutine instead closed over rc
+// directly, rc would stay reachable - and therefore never eligible for runtime.SetFinalizer to run
+// - for exactly as long as a caller forgot to call Close(), which is the one case the finalizer
+// safety net exists for. Keeping the sweeper's state here lets rc become unreachable (and get
+// finalized, which calls Close() and stops this goroutine) even if nothing else ever calls Close().
+type revisionCacheSweeper struct {
+	shards     []*revisionCacheShard
+	ttl        time.Duration
+	statsCache *expvar.Map
+	stopSweep  chan struct{}
+}
+
+// run periodically removes expired entries from the cache until stopSweep is closed.
+func (s *revisionCacheSweeper) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopSweep:
+			return
+		case <-ticker.C:
+			s.sweepExpired()
+		}
+	}
+}
+
+func (s *revisionCacheSweeper) sweepExpired() {
+	if s.ttl <= 0 {
+		return
+	}
+	for _, shard := range s.shards {
+		s.sweepShardExpired(shard)
+	}
+}
+
+func (s *revisionCacheSweeper) sweepShardExpired(shard *revisionCacheShard) {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	for key, value := range shard.cache {
+		if time.Since(value.insertedAt) > s.ttl {
+			delete(shard.cache, key)
+			shard.policy.Remove(value)
+			if s.statsCache != nil {
+				s.statsCache.Add(statRevCacheExpirations, 1)
+			}
+		}
+	}
+}
+
+// isExpired returns true if the cache has a TTL configured and the value has outlived it.
+func (rc *RevisionCache) isExpired(value *revCacheValue) bool {
+	return rc.ttl > 0 && time.Since(value.insertedAt) > rc.ttl
+}
+
+// addStat increments a named expvar counter, if stats are configured for this cache.
+func (rc *RevisionCache) addStat(name string, delta int64) {
+	if rc.statsCache == nil {
+		return
+	}
+	rc.statsCache.Add(name, delta)
+}
+
 // Looks up a revision from the cache.
 // Returns the body of the revision, its history, and the set of channels it's in.
 // If the cache has a loaderFunction, it will be called if the revision isn't in the cache;
@@ -90,22 +360,93 @@ func (rc *RevisionCache) GetWithCopy(docid, revid string, copyType BodyCopyType)
 	return rc.getFromCache(docid, revid, copyType, rc.loaderFunc != nil)
 }
 
+// documentRevisionPool recycles *DocumentRevision allocations for GetPooled/ReleaseDocumentRevision.
+var documentRevisionPool = sync.Pool{
+	New: func() interface{} { return new(DocumentRevision) },
+}
+
+// GetPooled behaves like Get, but returns a *DocumentRevision drawn from a sync.Pool instead of
+// allocating a fresh one, for hot, short-lived read paths (e.g. a BLIP or public REST handler that's
+// about to marshal the result and discard it). The caller must pass the result to
+// ReleaseDocumentRevision once they're done with it, and must not retain any reference to it
+// afterwards.
+func (rc *RevisionCache) GetPooled(docid, revid string) (*DocumentRevision, error) {
+	docRev, err := rc.Get(docid, revid)
+	pooled := documentRevisionPool.Get().(*DocumentRevision)
+	*pooled = docRev
+	return pooled, err
+}
+
+// ReleaseDocumentRevision returns a *DocumentRevision obtained from GetPooled to the pool. docRev
+// must not be used after this call.
+func ReleaseDocumentRevision(docRev *DocumentRevision) {
+	if docRev == nil {
+		return
+	}
+	*docRev = DocumentRevision{}
+	documentRevisionPool.Put(docRev)
+}
+
 // Attempt to update the delta on a revision cache entry.  If the entry is no longer resident in the cache,
-// fails silently
+// fails silently.  Evicts the least-recently-added delta for this revision if storing this one would
+// exceed the cache's per-revision delta limit.
 func (rc *RevisionCache) UpdateDelta(docID, revID string, toRevID string, delta []byte) {
 	value := rc.getValue(docID, revID, false)
 	if value != nil {
-		value.updateDelta(toRevID, delta)
+		value.updateDelta(toRevID, delta, rc.maxDeltas)
 	}
 }
 
+// GetDelta looks up an already-cached delta from fromRev to toRev, without affecting the
+// deltaRequestCount used by ShouldComputeDelta.  Returns false if fromRev isn't cached, or no delta
+// to toRev has been stored for it.
+func (rc *RevisionCache) GetDelta(docID, fromRev, toRev string) ([]byte, bool) {
+	value := rc.getValue(docID, fromRev, false)
+	if value == nil {
+		return nil, false
+	}
+	return value.getDelta(toRev)
+}
+
+// ShouldComputeDelta records a request for a delta from fromRev to toRev and reports whether it's
+// now been requested often enough (rc.minDeltaRequests) to be worth computing and storing via
+// UpdateDelta.  Callers that can cheaply serve the full revision instead should do so for requests
+// that return false, rather than spending CPU computing a delta that's unlikely to be reused.
+func (rc *RevisionCache) ShouldComputeDelta(docID, fromRev, toRev string) bool {
+	value := rc.getValue(docID, fromRev, false)
+	if value == nil {
+		return false
+	}
+	if value.recordDeltaRequest(toRev, rc.minDeltaRequests) {
+		rc.addStat(statRevCacheDeltaComputeServed, 1)
+		return true
+	}
+	rc.addStat(statRevCacheDeltaComputeAvoided, 1)
+	return false
+}
+
 func (rc *RevisionCache) getFromCache(docid, revid string, copyType BodyCopyType, loadOnCacheMiss bool) (DocumentRevision, error) {
 	value := rc.getValue(docid, revid, loadOnCacheMiss)
 	if value == nil {
 		return DocumentRevision{}, nil
 	}
-	docRev, statEvent, err := value.load(rc.loaderFunc, copyType)
+	if rc.isExpired(value) {
+		rc.removeValue(value)
+		rc.addStat(statRevCacheTTLEvictions, 1)
+		if !loadOnCacheMiss {
+			return DocumentRevision{}, nil
+		}
+		value = rc.getValue(docid, revid, true)
+	}
+	docRev, statEvent, l2Hit, err := value.load(rc.loaderFunc, rc.l2, rc.ttl, copyType)
 	rc.statsRecorderFunc(statEvent)
+	if !statEvent && rc.l2 != nil {
+		if l2Hit {
+			rc.addStat(statRevCacheL2Hits, 1)
+		} else {
+			rc.addStat(statRevCacheL2Misses, 1)
+		}
+	}
 
 	if err != nil {
 		rc.removeValue(value) // don't keep failed loads in the cache
@@ -131,6 +472,11 @@ func (rc *RevisionCache) GetActive(docid string, context *DatabaseContext) (docR
 
 	// Retrieve from or add to rev cache
 	value := rc.getValue(docid, bucketDoc.CurrentRev, true)
+	if rc.isExpired(value) {
+		rc.removeValue(value)
+		rc.addStat(statRevCacheTTLEvictions, 1)
+		value = rc.getValue(docid, bucketDoc.CurrentRev, true)
+	}
 	docRev, statEvent, err := value.loadForDoc(bucketDoc, context, BodyShallowCopy)
 	rc.statsRecorderFunc(statEvent)
 
@@ -165,39 +511,93 @@ func (rc *RevisionCache) getValue(docid, revid string, create bool) (value *revC
 		panic("RevisionCache: invalid empty doc/rev id")
 	}
 	key := IDAndRev{DocID: docid, RevID: revid}
-	rc.lock.Lock()
-	defer rc.lock.Unlock()
-	if elem := rc.cache[key]; elem != nil {
-		rc.lruList.MoveToFront(elem)
-		value = elem.Value.(*revCacheValue)
-	} else if create {
-		value = &revCacheValue{key: key}
-		rc.cache[key] = rc.lruList.PushFront(value)
-		for len(rc.cache) > int(rc.capacity) {
-			rc.purgeOldest_()
+	shard := rc.shardForKey(key)
+	shard.lock.Lock()
+	if existing := shard.cache[key]; existing != nil {
+		shard.policy.Touch(existing)
+		value = existing
+		shard.lock.Unlock()
+		return
+	}
+	if !create {
+		shard.lock.Unlock()
+		return
+	}
+	if uint32(len(shard.cache)) >= shard.capacity && !shard.policy.Admit(key) {
+		// The admission filter (only meaningful for TinyLFU; other policies always admit)
+		// declined to let this key displace a hotter one. Serve this one request without
+		// caching it, rather than failing the lookup outright.
+		shard.lock.Unlock()
+		return &revCacheValue{key: key, insertedAt: time.Now()}
+	}
+	value = &revCacheValue{key: key, insertedAt: time.Now()}
+	shard.cache[key] = value
+	shard.policy.Touch(value)
+	var evicted []*revCacheValue
+	for uint32(len(shard.cache)) > shard.capacity {
+		candidate, ok := shard.policy.Evict()
+		if !ok {
+			break
 		}
+		if shard.cache[candidate.key] == candidate {
+			delete(shard.cache, candidate.key)
+			evicted = append(evicted, candidate)
+		}
+	}
+	shard.lock.Unlock()
+
+	// writeThroughL2 does synchronous disk I/O (a BoltDB update, with fsync) - do it after releasing
+	// the shard lock so an eviction doesn't block every other document hashed to this shard behind
+	// disk latency.
+	for _, v := range evicted {
+		rc.writeThroughL2(v)
 	}
 	return
 }
 
 func (rc *RevisionCache) removeValue(value *revCacheValue) {
-	rc.lock.Lock()
-	if element := rc.cache[value.key]; element != nil && element.Value == value {
-		rc.lruList.Remove(element)
-		delete(rc.cache, value.key)
+	shard := rc.shardForKey(value.key)
+	shard.lock.Lock()
+	if existing := shard.cache[value.key]; existing == value {
+		delete(shard.cache, value.key)
+		shard.policy.Remove(value)
 	}
-	rc.lock.Unlock()
+	shard.lock.Unlock()
 }
 
-func (rc *RevisionCache) purgeOldest_() {
-	value := rc.lruList.Remove(rc.lruList.Back()).(*revCacheValue)
-	delete(rc.cache, value.key)
+// writeThroughL2 persists an evicted entry's data to the L2 tier, if one is configured.  Entries
+// that were never actually loaded (e.g. a placeholder created but never populated) have nothing
+// worth persisting.
+func (rc *RevisionCache) writeThroughL2(value *revCacheValue) {
+	if rc.l2 == nil {
+		return
+	}
+	value.lock.Lock()
+	docRev := DocumentRevision{
+		RevID:       value.key.RevID,
+		Body:        value.body,
+		History:     value.history,
+		Channels:    value.channels,
+		Expiry:      value.expiry,
+		Attachments: value.attachments,
+	}
+	hasBody := value.body != nil
+	value.lock.Unlock()
+
+	if !hasBody {
+		return
+	}
+	rc.l2.Put(value.key, docRev)
 }
 
-// Gets the body etc. out of a revCacheValue. If they aren't present already, the loader func
-// will be called. This is synchronized so that the loader will only be called once even if
-// multiple goroutines try to load at the same time.
-func (value *revCacheValue) load(loaderFunc RevisionCacheLoaderFunc, copyType BodyCopyType) (docRev DocumentRevision, cacheHit bool, err error) {
+// Gets the body etc. out of a revCacheValue. If they aren't present already, the L2 tier (if any)
+// is checked before falling back to the loader func. This is synchronized so that the loader will
+// only be called once even if multiple goroutines try to load at the same time.
+//
+// ttl is the cache's configured expiration, if any. An L2 hit whose writtenAt predates ttl is
+// treated as a miss rather than trusted as-is - L2 entries carry their own write time distinct
+// from insertedAt, so a stale L2 entry can't silently re-arm the TTL clock for this value.
+func (value *revCacheValue) load(loaderFunc RevisionCacheLoaderFunc, l2 RevisionCacheL2, ttl time.Duration, copyType BodyCopyType) (docRev DocumentRevision, cacheHit bool, l2Hit bool, err error) {
 
 	value.lock.Lock()
 	defer value.lock.Unlock()
@@ -205,7 +605,18 @@ func (value *revCacheValue) load(loaderFunc RevisionCacheLoaderFunc, copyType Bo
 	cacheHit = true
 	if value.body == nil && value.err == nil {
 		cacheHit = false
-		if loaderFunc != nil {
+		if l2 != nil {
+			if body, history, channels, attachments, expiry, writtenAt, found := l2.Get(value.key); found && (ttl <= 0 || time.Since(writtenAt) <= ttl) {
+				value.body = body
+				value.history = history
+				value.channels = channels
+				value.attachments = attachments
+				value.expiry = expiry
+				value.insertedAt = writtenAt
+				l2Hit = true
+			}
+		}
+		if value.body == nil && loaderFunc != nil {
 			value.body, value.history, value.channels, value.attachments, value.expiry, value.err = loaderFunc(value.key)
 		}
 	}
@@ -217,9 +628,8 @@ func (value *revCacheValue) load(loaderFunc RevisionCacheLoaderFunc, copyType Bo
 		Channels:    value.channels,
 		Expiry:      value.expiry,
 		Attachments: value.attachments.ShallowCopy(), // Avoid caller mutating the stored attachments
-		Delta:       value.delta,
 	}
-	return docRev, cacheHit, value.err
+	return docRev, cacheHit, l2Hit, value.err
 }
 
 // Retrieves the body etc. out of a revCacheValue.  If they aren't already present, loads into the cache value using
@@ -242,7 +652,6 @@ func (value *revCacheValue) loadForDoc(doc *document, context *DatabaseContext,
 		Channels:    value.channels,
 		Expiry:      value.expiry,
 		Attachments: value.attachments.ShallowCopy(), // Avoid caller mutating the stored attachments
-		Delta:       value.delta,
 	}
 
 	return docRev, cacheHit, value.err
@@ -264,11 +673,46 @@ func (value *revCacheValue) store(docRev DocumentRevision) {
 	}
 }
 
-func (value *revCacheValue) updateDelta(toRevID string, deltaBytes []byte) {
+// updateDelta stores a delta to toRevID, evicting the oldest-inserted delta for this revision if
+// storing it would exceed maxDeltas.
+func (value *revCacheValue) updateDelta(toRevID string, deltaBytes []byte, maxDeltas int) {
 	value.lock.Lock()
 	defer value.lock.Unlock()
-	value.delta = &RevCacheDelta{
+	if value.deltas == nil {
+		value.deltas = map[string]*RevCacheDelta{}
+	}
+	if _, exists := value.deltas[toRevID]; !exists {
+		value.deltaOrder = append(value.deltaOrder, toRevID)
+		for len(value.deltaOrder) > maxDeltas {
+			oldest := value.deltaOrder[0]
+			value.deltaOrder = value.deltaOrder[1:]
+			delete(value.deltas, oldest)
+		}
+	}
+	value.deltas[toRevID] = &RevCacheDelta{
 		ToRevID:    toRevID,
 		DeltaBytes: deltaBytes,
 	}
 }
+
+// getDelta returns the cached delta to toRevID, if any.
+func (value *revCacheValue) getDelta(toRevID string) ([]byte, bool) {
+	value.lock.Lock()
+	defer value.lock.Unlock()
+	if d, ok := value.deltas[toRevID]; ok {
+		return d.DeltaBytes, true
+	}
+	return nil, false
+}
+
+// recordDeltaRequest increments the request count for the (this revision, toRevID) pair and
+// reports whether it has now been requested at least minDeltaRequests times.
+func (value *revCacheValue) recordDeltaRequest(toRevID string, minDeltaRequests int) bool {
+	value.lock.Lock()
+	defer value.lock.Unlock()
+	if value.deltaRequestCount == nil {
+		value.deltaRequestCount = map[string]int{}
+	}
+	value.deltaRequestCount[toRevID]++
+	return value.deltaRequestCount[toRevID] >= minDeltaRequests
+}
@@ -0,0 +1,57 @@
+package db
+
+import "testing"
+
+// TestRevisionCacheDeltaThreshold checks that ShouldComputeDelta only returns true once a (from,to)
+// pair has been requested minDeltaRequests times, and that GetDelta doesn't itself count as a
+// request - only ShouldComputeDelta does.
+func TestRevisionCacheDeltaThreshold(t *testing.T) {
+	// A multiple of DefaultRevisionCacheShardCount so every shard gets equal, non-zero capacity
+	// regardless of which shard the test key happens to hash to.
+	rc := NewRevisionCacheWithDeltaPolicy(2*DefaultRevisionCacheShardCount, countingLoader(new(int)), nil, DefaultMaxDeltasPerRevision, 3)
+	if _, err := rc.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rc.ShouldComputeDelta("doc1", "1-abc", "2-def") {
+		t.Fatalf("expected first request not to reach the threshold")
+	}
+	if rc.ShouldComputeDelta("doc1", "1-abc", "2-def") {
+		t.Fatalf("expected second request not to reach the threshold")
+	}
+	if !rc.ShouldComputeDelta("doc1", "1-abc", "2-def") {
+		t.Fatalf("expected third request to reach the threshold")
+	}
+
+	rc.UpdateDelta("doc1", "1-abc", "2-def", []byte("delta"))
+	if _, ok := rc.GetDelta("doc1", "1-abc", "2-def"); !ok {
+		t.Fatalf("expected delta to be retrievable after UpdateDelta")
+	}
+	if _, ok := rc.GetDelta("doc1", "1-abc", "3-ghi"); ok {
+		t.Fatalf("expected no delta for a target that was never stored")
+	}
+}
+
+// TestRevisionCacheDeltaEviction checks that once a cached revision's delta chain reaches
+// maxDeltas, the oldest-added delta is evicted to make room for a new one.
+func TestRevisionCacheDeltaEviction(t *testing.T) {
+	const maxDeltas = 2
+	rc := NewRevisionCacheWithDeltaPolicy(2*DefaultRevisionCacheShardCount, countingLoader(new(int)), nil, maxDeltas, 1)
+	if _, err := rc.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rc.UpdateDelta("doc1", "1-abc", "2-def", []byte("to-2"))
+	rc.UpdateDelta("doc1", "1-abc", "3-ghi", []byte("to-3"))
+	rc.UpdateDelta("doc1", "1-abc", "4-jkl", []byte("to-4"))
+
+	if _, ok := rc.GetDelta("doc1", "1-abc", "2-def"); ok {
+		t.Fatalf("expected oldest delta to have been evicted once maxDeltas was exceeded")
+	}
+	if _, ok := rc.GetDelta("doc1", "1-abc", "3-ghi"); !ok {
+		t.Fatalf("expected delta to 3-ghi to still be resident")
+	}
+	if _, ok := rc.GetDelta("doc1", "1-abc", "4-jkl"); !ok {
+		t.Fatalf("expected most recently added delta to still be resident")
+	}
+}
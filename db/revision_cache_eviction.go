@@ -0,0 +1,527 @@
+package db
+
+import (
+	"container/heap"
+)
+
+// EvictionPolicy decides which entries are resident in a revisionCacheShard and which one to evict
+// next once the shard is over capacity. Implementations are not safe for concurrent use on their
+// own; the owning revisionCacheShard's lock guards all calls.
+type EvictionPolicy interface {
+	// Touch records that value was just accessed, whether that's a fresh insert or a cache hit.
+	Touch(value *revCacheValue)
+	// Admit reports whether key should be allowed to displace an existing entry now that the shard
+	// is at capacity. Policies that don't filter admission (LRU, LFU) always return true; TinyLFU
+	// uses this to resist scan pollution from one-off bulk reads. Admit is key-based rather than
+	// value-based because a candidate being considered for admission has no *revCacheValue yet.
+	Admit(key IDAndRev) bool
+	// Evict selects and forgets the next value to evict. Returns false if the policy holds nothing.
+	Evict() (*revCacheValue, bool)
+	// Remove drops value from the policy's bookkeeping, e.g. because it expired or failed to load.
+	Remove(value *revCacheValue)
+}
+
+// EvictionPolicyFactory builds an EvictionPolicy sized for a shard of the given capacity. Each
+// shard of a RevisionCache calls this once, at construction, to get its own independent policy
+// instance.
+type EvictionPolicyFactory func(capacity uint32) EvictionPolicy
+
+// lruEvictionPolicy is the original RevisionCache eviction behavior: evict whatever was least
+// recently touched. It threads its list directly through *revCacheValue's lruPrev/lruNext fields
+// rather than through a parallel key-indexed structure, so a touch or eviction costs no map lookup
+// and no extra allocation - this is the hot path, used by every shard unless a policy is configured
+// explicitly.
+type lruEvictionPolicy struct {
+	head, tail *revCacheValue
+}
+
+// NewLRUEvictionPolicy is the default EvictionPolicyFactory: classic least-recently-used eviction.
+func NewLRUEvictionPolicy(capacity uint32) EvictionPolicy {
+	return &lruEvictionPolicy{}
+}
+
+func (p *lruEvictionPolicy) link(v *revCacheValue) {
+	v.lruPrev = nil
+	v.lruNext = p.head
+	if p.head != nil {
+		p.head.lruPrev = v
+	}
+	p.head = v
+	if p.tail == nil {
+		p.tail = v
+	}
+}
+
+func (p *lruEvictionPolicy) unlink(v *revCacheValue) {
+	if v.lruPrev != nil {
+		v.lruPrev.lruNext = v.lruNext
+	} else {
+		p.head = v.lruNext
+	}
+	if v.lruNext != nil {
+		v.lruNext.lruPrev = v.lruPrev
+	} else {
+		p.tail = v.lruPrev
+	}
+	v.lruPrev = nil
+	v.lruNext = nil
+}
+
+// isLinked reports whether v is currently in the list. v.lruPrev/lruNext alone can't tell a node
+// that's not yet linked apart from the sole node in a one-element list, so head/tail are checked too.
+func (p *lruEvictionPolicy) isLinked(v *revCacheValue) bool {
+	return p.head == v || p.tail == v || v.lruPrev != nil || v.lruNext != nil
+}
+
+func (p *lruEvictionPolicy) Touch(v *revCacheValue) {
+	if p.head == v {
+		return
+	}
+	if p.isLinked(v) {
+		p.unlink(v)
+	}
+	p.link(v)
+}
+
+func (p *lruEvictionPolicy) Admit(key IDAndRev) bool { return true }
+
+func (p *lruEvictionPolicy) Evict() (*revCacheValue, bool) {
+	if p.tail == nil {
+		return nil, false
+	}
+	v := p.tail
+	p.unlink(v)
+	return v, true
+}
+
+func (p *lruEvictionPolicy) Remove(v *revCacheValue) {
+	if !p.isLinked(v) {
+		return
+	}
+	p.unlink(v)
+}
+
+// valueLRUNode is one entry in a valueLRUList.
+type valueLRUNode struct {
+	value      *revCacheValue
+	prev, next *valueLRUNode
+}
+
+// valueLRUList is an intrusive doubly-linked list of *revCacheValue, with O(1) lookup by key. LFU
+// and TinyLFU need a separate ordering structure like this one - LFU orders by frequency rather
+// than recency, and TinyLFU keeps three of these (window/probationary/protected) - unlike plain
+// LRU, which can thread its single list straight through revCacheValue itself (see
+// lruEvictionPolicy) and skip this indirection entirely.
+type valueLRUList struct {
+	nodes      map[IDAndRev]*valueLRUNode
+	head, tail *valueLRUNode
+}
+
+func newValueLRUList() *valueLRUList {
+	return &valueLRUList{nodes: map[IDAndRev]*valueLRUNode{}}
+}
+
+func (l *valueLRUList) len() int {
+	return len(l.nodes)
+}
+
+func (l *valueLRUList) link(node *valueLRUNode) {
+	node.prev = nil
+	node.next = l.head
+	if l.head != nil {
+		l.head.prev = node
+	}
+	l.head = node
+	if l.tail == nil {
+		l.tail = node
+	}
+}
+
+func (l *valueLRUList) unlink(node *valueLRUNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		l.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+}
+
+// pushFront inserts value at the most-recently-used end. value's key must not already be in the list.
+func (l *valueLRUList) pushFront(value *revCacheValue) {
+	node := &valueLRUNode{value: value}
+	l.link(node)
+	l.nodes[value.key] = node
+}
+
+// touch marks value as most-recently-used, inserting it if it isn't already present.
+func (l *valueLRUList) touch(value *revCacheValue) {
+	if node, ok := l.nodes[value.key]; ok {
+		if l.head != node {
+			l.unlink(node)
+			l.link(node)
+		}
+		return
+	}
+	l.pushFront(value)
+}
+
+// remove drops key from the list, if present.
+func (l *valueLRUList) remove(key IDAndRev) {
+	node, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	l.unlink(node)
+	delete(l.nodes, key)
+}
+
+// removeOldest evicts and returns the least-recently-used value. Returns false if the list is empty.
+func (l *valueLRUList) removeOldest() (*revCacheValue, bool) {
+	if l.tail == nil {
+		return nil, false
+	}
+	value := l.tail.value
+	l.unlink(l.tail)
+	delete(l.nodes, value.key)
+	return value, true
+}
+
+// lfuNode is one entry in an lfuHeap; index is maintained by container/heap so Remove can do an
+// O(log n) heap.Remove instead of a linear scan.
+type lfuNode struct {
+	value *revCacheValue
+	freq  int
+	index int
+}
+
+// lfuHeap is a min-heap of lfuNodes ordered by access frequency, so the least-frequently-used key
+// is always at the root.
+type lfuHeap []*lfuNode
+
+func (h lfuHeap) Len() int            { return len(h) }
+func (h lfuHeap) Less(i, j int) bool  { return h[i].freq < h[j].freq }
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	node := x.(*lfuNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	old[n-1] = nil
+	node.index = -1
+	*h = old[:n-1]
+	return node
+}
+
+// lfuEvictionPolicy evicts the value with the fewest recorded accesses, using a min-heap so that
+// both Touch (frequency bump) and Evict are O(log n). It still needs nodes keyed by IDAndRev -
+// unlike plain LRU, frequency order has nothing to do with recency, so there's no single list to
+// thread through *revCacheValue directly.
+type lfuEvictionPolicy struct {
+	heap  lfuHeap
+	nodes map[IDAndRev]*lfuNode
+}
+
+// NewLFUEvictionPolicy is an EvictionPolicyFactory for classic least-frequently-used eviction.
+func NewLFUEvictionPolicy(capacity uint32) EvictionPolicy {
+	return &lfuEvictionPolicy{nodes: map[IDAndRev]*lfuNode{}}
+}
+
+func (p *lfuEvictionPolicy) Touch(value *revCacheValue) {
+	if node, ok := p.nodes[value.key]; ok {
+		node.freq++
+		heap.Fix(&p.heap, node.index)
+		return
+	}
+	node := &lfuNode{value: value, freq: 1}
+	p.nodes[value.key] = node
+	heap.Push(&p.heap, node)
+}
+
+func (p *lfuEvictionPolicy) Admit(key IDAndRev) bool { return true }
+
+func (p *lfuEvictionPolicy) Evict() (*revCacheValue, bool) {
+	if p.heap.Len() == 0 {
+		return nil, false
+	}
+	node := heap.Pop(&p.heap).(*lfuNode)
+	delete(p.nodes, node.value.key)
+	return node.value, true
+}
+
+func (p *lfuEvictionPolicy) Remove(value *revCacheValue) {
+	node, ok := p.nodes[value.key]
+	if !ok {
+		return
+	}
+	heap.Remove(&p.heap, node.index)
+	delete(p.nodes, value.key)
+}
+
+// cmsSeeds are the multiplicative seeds for the count-min sketch's 4 hash functions. Distinct
+// non-trivial odd constants are enough to decorrelate the rows for our purposes; this doesn't need
+// to be cryptographically independent, just different enough that one key rarely collides with
+// another in every row at once.
+var cmsSeeds = [4]uint32{2166136261, 2654435761, 40503, 2246822519}
+
+// countMinSketch is an approximate frequency counter: it never undercounts, but distinct keys that
+// collide in every row can cause overcounting. Counters are halved (log-decay) once the total
+// number of increments reaches width*10, so the sketch reflects recent access patterns rather than
+// all-time totals.
+type countMinSketch struct {
+	width     int
+	rows      [4][]uint8
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	if width < 1 {
+		width = 1
+	}
+	var rows [4][]uint8
+	for i := range rows {
+		rows[i] = make([]uint8, width)
+	}
+	return &countMinSketch{width: width, rows: rows, resetAt: width * 10}
+}
+
+func (s *countMinSketch) hash(key IDAndRev, seed uint32) int {
+	return int(fnv32Seeded(key.DocID+"@"+key.RevID, seed) % uint32(s.width))
+}
+
+// fnv32Seeded is fnv32 with a caller-supplied offset basis, so the count-min sketch can derive
+// several differently-distributed hashes of the same key without needing several hash algorithms.
+func fnv32Seeded(s string, seed uint32) uint32 {
+	const prime32 = 16777619
+	hash := seed
+	for i := 0; i < len(s); i++ {
+		hash *= prime32
+		hash ^= uint32(s[i])
+	}
+	return hash
+}
+
+func (s *countMinSketch) increment(key IDAndRev) {
+	for i, seed := range cmsSeeds {
+		idx := s.hash(key, seed)
+		if s.rows[i][idx] < 255 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.decay()
+	}
+}
+
+// decay halves every counter, approximating a decaying window so that a key's estimate reflects
+// recent popularity rather than accumulating forever.
+func (s *countMinSketch) decay() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions = 0
+}
+
+func (s *countMinSketch) estimate(key IDAndRev) uint8 {
+	min := uint8(255)
+	for i, seed := range cmsSeeds {
+		if v := s.rows[i][s.hash(key, seed)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// tinyLFUSegment identifies which of the TinyLFU policy's three lists a key currently lives in.
+type tinyLFUSegment int
+
+const (
+	segmentWindow tinyLFUSegment = iota
+	segmentProbationary
+	segmentProtected
+)
+
+// tinyLFUEvictionPolicy is a W-TinyLFU policy: a small admission-window LRU plus a segmented (SLRU)
+// main cache, gated by a count-min sketch frequency estimate. This resists "scan pollution" - a
+// burst of one-off reads, such as an initial replicator pull, evicting durably popular entries -
+// because a new key only displaces a resident one when the sketch says it's actually accessed more
+// often, rather than merely more recently.
+type tinyLFUEvictionPolicy struct {
+	sketch *countMinSketch
+
+	window         *valueLRUList
+	windowCapacity int
+
+	probationary         *valueLRUList
+	probationaryCapacity int
+
+	protected         *valueLRUList
+	protectedCapacity int
+
+	location map[IDAndRev]tinyLFUSegment
+}
+
+// NewTinyLFUEvictionPolicy is an EvictionPolicyFactory for W-TinyLFU eviction: a count-min sketch
+// admission filter gating a small (1% of capacity) LRU window and a larger (99%) SLRU main cache.
+func NewTinyLFUEvictionPolicy(capacity uint32) EvictionPolicy {
+	if capacity == 0 {
+		capacity = 1
+	}
+
+	windowCapacity := int(capacity) / 100
+	if windowCapacity < 1 {
+		windowCapacity = 1
+	}
+	mainCapacity := int(capacity) - windowCapacity
+	if mainCapacity < 1 {
+		mainCapacity = 1
+	}
+	// Within the main cache, most capacity is reserved for "protected" (proven-popular) entries;
+	// the rest is "probationary" space new promotions from the window have to survive in first.
+	protectedCapacity := mainCapacity * 80 / 100
+	if protectedCapacity < 1 {
+		protectedCapacity = 1
+	}
+	probationaryCapacity := mainCapacity - protectedCapacity
+	if probationaryCapacity < 1 {
+		probationaryCapacity = 1
+	}
+
+	return &tinyLFUEvictionPolicy{
+		sketch:               newCountMinSketch(int(capacity) * 10),
+		window:               newValueLRUList(),
+		windowCapacity:       windowCapacity,
+		probationary:         newValueLRUList(),
+		probationaryCapacity: probationaryCapacity,
+		protected:            newValueLRUList(),
+		protectedCapacity:    protectedCapacity,
+		location:             map[IDAndRev]tinyLFUSegment{},
+	}
+}
+
+func (p *tinyLFUEvictionPolicy) Touch(value *revCacheValue) {
+	p.sketch.increment(value.key)
+	switch p.location[value.key] {
+	case segmentProtected:
+		p.protected.touch(value)
+	case segmentProbationary:
+		// A second access while still on probation is enough to prove it's not a one-off scan;
+		// promote it to protected.
+		p.probationary.remove(value.key)
+		p.protected.pushFront(value)
+		p.location[value.key] = segmentProtected
+		p.demoteProtectedOverflow()
+	case segmentWindow:
+		p.window.touch(value)
+	default:
+		p.window.pushFront(value)
+		p.location[value.key] = segmentWindow
+		p.promoteWindowOverflow()
+	}
+}
+
+// demoteProtectedOverflow pushes the coldest protected entries back down to probationary once
+// protected exceeds its capacity share.
+func (p *tinyLFUEvictionPolicy) demoteProtectedOverflow() {
+	for p.protected.len() > p.protectedCapacity {
+		value, ok := p.protected.removeOldest()
+		if !ok {
+			break
+		}
+		p.probationary.pushFront(value)
+		p.location[value.key] = segmentProbationary
+	}
+}
+
+// promoteWindowOverflow moves the coldest window entries into probationary once the window exceeds
+// its capacity share, where they'll have to compete for admission like any other candidate.
+func (p *tinyLFUEvictionPolicy) promoteWindowOverflow() {
+	for p.window.len() > p.windowCapacity {
+		value, ok := p.window.removeOldest()
+		if !ok {
+			break
+		}
+		p.probationary.pushFront(value)
+		p.location[value.key] = segmentProbationary
+	}
+}
+
+// victim returns the key that would be evicted right now, without actually evicting it, so Admit
+// can compare a candidate's estimated frequency against it.
+func (p *tinyLFUEvictionPolicy) victim() (IDAndRev, bool) {
+	if p.probationary.tail != nil {
+		return p.probationary.tail.value.key, true
+	}
+	if p.window.tail != nil {
+		return p.window.tail.value.key, true
+	}
+	return IDAndRev{}, false
+}
+
+func (p *tinyLFUEvictionPolicy) Admit(key IDAndRev) bool {
+	victimKey, ok := p.victim()
+	if !ok {
+		return true
+	}
+	admitted := p.sketch.estimate(key) > p.sketch.estimate(victimKey)
+	if !admitted {
+		// An admitted key gets credited via the Touch() call that follows in getValue, but a
+		// declined candidate has no other path to accumulate frequency - without this, a key stuck
+		// at an estimate of 0 could never subsequently outscore a victim's estimate, and a shard
+		// that's once filled would refuse every new key forever, no matter how often it's
+		// requested. Counting the attempt here lets a genuinely popular new key eventually win.
+		p.sketch.increment(key)
+	}
+	return admitted
+}
+
+func (p *tinyLFUEvictionPolicy) Evict() (*revCacheValue, bool) {
+	if value, ok := p.probationary.removeOldest(); ok {
+		delete(p.location, value.key)
+		return value, true
+	}
+	if value, ok := p.window.removeOldest(); ok {
+		delete(p.location, value.key)
+		return value, true
+	}
+	// Only reached once probationary and window are both empty - i.e. capacity is so small that
+	// everything lives in protected.
+	if value, ok := p.protected.removeOldest(); ok {
+		delete(p.location, value.key)
+		return value, true
+	}
+	return nil, false
+}
+
+func (p *tinyLFUEvictionPolicy) Remove(value *revCacheValue) {
+	switch p.location[value.key] {
+	case segmentProtected:
+		p.protected.remove(value.key)
+	case segmentProbationary:
+		p.probationary.remove(value.key)
+	case segmentWindow:
+		p.window.remove(value.key)
+	default:
+		return
+	}
+	delete(p.location, value.key)
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestLRUEvictionPolicyOrder checks that lruEvictionPolicy evicts least-recently-touched values
+// first, and that re-touching a value protects it from an eviction it would otherwise suffer.
+func TestLRUEvictionPolicyOrder(t *testing.T) {
+	rc := newRevisionCache(2, 1, NewLRUEvictionPolicy, countingLoader(new(int)), nil)
+
+	for _, id := range []string{"1-a", "2-b"} {
+		if _, err := rc.Get("doc", id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Touch 1-a again so 2-b becomes the least-recently-used entry.
+	if _, err := rc.Get("doc", "1-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := rc.Get("doc", "3-c"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rc.getValue("doc", "2-b", false) != nil {
+		t.Fatalf("expected 2-b to have been evicted as least-recently-used")
+	}
+	if rc.getValue("doc", "1-a", false) == nil {
+		t.Fatalf("expected 1-a to still be resident after being re-touched")
+	}
+	if rc.getValue("doc", "3-c", false) == nil {
+		t.Fatalf("expected 3-c to be resident as the most recent insert")
+	}
+}
+
+// TestLFUEvictionPolicyOrder checks that lfuEvictionPolicy evicts the least-frequently-accessed
+// value, even when it isn't the least-recently-accessed one.
+func TestLFUEvictionPolicyOrder(t *testing.T) {
+	rc := newRevisionCache(2, 1, NewLFUEvictionPolicy, countingLoader(new(int)), nil)
+
+	if _, err := rc.Get("doc", "1-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := rc.Get("doc", "2-b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Access 1-a twice more so it's strictly more frequent than 2-b, even though 2-b was touched
+	// more recently.
+	if _, err := rc.Get("doc", "1-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := rc.Get("doc", "1-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := rc.Get("doc", "3-c"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rc.getValue("doc", "2-b", false) != nil {
+		t.Fatalf("expected 2-b to have been evicted as least-frequently-used")
+	}
+	if rc.getValue("doc", "1-a", false) == nil {
+		t.Fatalf("expected 1-a to still be resident as the more frequently accessed entry")
+	}
+}
+
+// TestTinyLFUEvictionPolicyResistsScanPollution checks the defining property of W-TinyLFU: a burst
+// of one-off reads (a "scan") shouldn't be able to evict entries that are durably popular, even
+// though the scan is more recent.
+func TestTinyLFUEvictionPolicyResistsScanPollution(t *testing.T) {
+	const capacity = 20
+	rc := newRevisionCache(capacity, 1, NewTinyLFUEvictionPolicy, countingLoader(new(int)), nil)
+
+	// Establish doc0 as popular, well above the threshold a single admission needs to clear.
+	for i := 0; i < 20; i++ {
+		if _, err := rc.Get("popular", "1-a"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// A scan of many one-off keys, each touched exactly once, shouldn't be able to fully displace
+	// the popular entry's spot in the protected segment.
+	for i := 0; i < 200; i++ {
+		if _, err := rc.Get(fmt.Sprintf("scan%d", i), "1-a"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if rc.getValue("popular", "1-a", false) == nil {
+		t.Fatalf("expected popular entry to survive a scan of one-off reads")
+	}
+}
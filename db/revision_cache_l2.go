@@ -0,0 +1,259 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// revCacheL2Bucket is the BoltDB bucket all revision cache overflow entries are stored in.
+var revCacheL2Bucket = []byte("revCache")
+
+// RevisionCacheL2 is the optional on-disk overflow tier for a RevisionCache.  Entries evicted from
+// the in-memory LRU are written here, and consulted before falling back to the bucket loader on a
+// cache miss, so that bulk replication catch-up doesn't have to round-trip to Couchbase Server for
+// revisions that were merely LRU-evicted rather than genuinely cold.
+type RevisionCacheL2 interface {
+	// Get returns the stored body/history/channels/attachments/expiry for key, along with the time
+	// it was written to L2, if present. writtenAt lets a caller with a configured cache TTL detect
+	// an entry that's stale relative to that TTL, even though it's still resident in L2.
+	Get(key IDAndRev) (body Body, history Revisions, channels base.Set, attachments AttachmentsMeta, expiry *time.Time, writtenAt time.Time, found bool)
+	// Put stores docRev under key, overwriting any existing entry.
+	Put(key IDAndRev, docRev DocumentRevision)
+	// Delete removes any entry stored under key.
+	Delete(key IDAndRev)
+}
+
+// revCacheL2Payload is the on-disk representation of an L2 entry.  It mirrors the fields of
+// revCacheValue that are worth persisting, but with exported fields so it can round-trip through
+// encoding/json.
+type revCacheL2Payload struct {
+	Body        Body
+	History     Revisions
+	Channels    base.Set
+	Attachments AttachmentsMeta
+	Expiry      *time.Time
+	WrittenAt   time.Time // When this entry was written to L2; lets a configured cache TTL detect staleness
+}
+
+// BoltRevisionCacheL2 is a RevisionCacheL2 backed by a local BoltDB file.  Payloads are gzip
+// compressed JSON, keyed by "<docid>@<revid>".  Entries are trimmed FIFO-by-insertion once the
+// store exceeds maxBytes (0 means unlimited).
+type BoltRevisionCacheL2 struct {
+	db         *bolt.DB
+	maxBytes   int64
+	statsCache *expvar.Map
+
+	lock  sync.Mutex // Guards insertOrder and size; BoltDB itself is already safe for concurrent use
+	order []string   // Keys in insertion order, oldest first, for FIFO trimming
+	size  int64      // Total bytes currently stored
+}
+
+// NewBoltRevisionCacheL2 opens (creating if necessary) a BoltDB-backed L2 tier at path, capped at
+// maxBytes of compressed payload data (0 means unlimited).
+func NewBoltRevisionCacheL2(path string, maxBytes int64, statsCache *expvar.Map) (*BoltRevisionCacheL2, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(revCacheL2Bucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	l2 := &BoltRevisionCacheL2{
+		db:         db,
+		maxBytes:   maxBytes,
+		statsCache: statsCache,
+	}
+	if err := l2.loadExistingKeys(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return l2, nil
+}
+
+// loadExistingKeys populates insertion order and size bookkeeping from an already-populated store
+// (e.g. after a Sync Gateway restart).  BoltDB iterates a bucket in key-sorted order rather than
+// insertion order, so this is only an approximation of original insertion order, but it's good
+// enough to bound disk usage going forward.
+func (l2 *BoltRevisionCacheL2) loadExistingKeys() error {
+	return l2.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revCacheL2Bucket)
+		return bucket.ForEach(func(k, v []byte) error {
+			l2.order = append(l2.order, string(k))
+			l2.size += int64(len(v))
+			return nil
+		})
+	})
+}
+
+func l2Key(key IDAndRev) []byte {
+	return []byte(key.DocID + "@" + key.RevID)
+}
+
+// Get implements RevisionCacheL2.
+func (l2 *BoltRevisionCacheL2) Get(key IDAndRev) (body Body, history Revisions, channels base.Set, attachments AttachmentsMeta, expiry *time.Time, writtenAt time.Time, found bool) {
+	var payload revCacheL2Payload
+	err := l2.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(revCacheL2Bucket).Get(l2Key(key))
+		if data == nil {
+			return nil
+		}
+		if err := decodeGzipJSON(data, &payload); err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+	if err != nil || !found {
+		return nil, nil, nil, nil, nil, time.Time{}, false
+	}
+	return payload.Body, payload.History, payload.Channels, payload.Attachments, payload.Expiry, payload.WrittenAt, true
+}
+
+// Put implements RevisionCacheL2.
+func (l2 *BoltRevisionCacheL2) Put(key IDAndRev, docRev DocumentRevision) {
+	payload := revCacheL2Payload{
+		Body:        docRev.Body,
+		History:     docRev.History,
+		Channels:    docRev.Channels,
+		Attachments: docRev.Attachments,
+		Expiry:      docRev.Expiry,
+		WrittenAt:   time.Now(),
+	}
+	// Best-effort: a failure to persist to the overflow tier just means a future cache miss will
+	// fall back to the bucket loader, same as if the entry had never been evicted here.
+	data, err := encodeGzipJSON(payload)
+	if err != nil {
+		return
+	}
+
+	keyBytes := l2Key(key)
+
+	// l2.lock is held across the BoltDB write, not just the order/size bookkeeping after it: the
+	// existing-key check and the bookkeeping update it drives have to be atomic with each other, or
+	// two concurrent Puts for the same key (e.g. evicted, reloaded, evicted again in quick
+	// succession) can interleave and corrupt order/size exactly as a missing dedup would. This adds
+	// no real contention beyond what BoltDB already imposes - db.Update only ever allows one writer
+	// transaction at a time - and deleteKey_ already does disk I/O under this same lock.
+	l2.lock.Lock()
+
+	var oldSize int64
+	var hadExisting bool
+	if err := l2.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revCacheL2Bucket)
+		if old := bucket.Get(keyBytes); old != nil {
+			oldSize = int64(len(old))
+			hadExisting = true
+		}
+		return bucket.Put(keyBytes, data)
+	}); err != nil {
+		l2.lock.Unlock()
+		return
+	}
+
+	if hadExisting {
+		// A re-Put of an already-resident key (evicted to L2, reloaded into L1, evicted again) must
+		// replace its order/size bookkeeping rather than append to it - otherwise order accumulates
+		// a stale duplicate that, once trimToCapacity_ reaches it, deletes the live entry this Put
+		// just wrote under the mistaken belief it's the oldest generation.
+		l2.removeFromOrder_(string(keyBytes))
+		l2.size -= oldSize
+	}
+	l2.order = append(l2.order, string(keyBytes))
+	l2.size += int64(len(data))
+	l2.trimToCapacity_()
+	l2.lock.Unlock()
+
+	l2.setBytesStat()
+}
+
+// removeFromOrder_ splices key's first occurrence out of l2.order, if present.  Must be called with
+// l2.lock held.
+func (l2 *BoltRevisionCacheL2) removeFromOrder_(key string) {
+	for i, k := range l2.order {
+		if k == key {
+			l2.order = append(l2.order[:i], l2.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// Delete implements RevisionCacheL2.
+func (l2 *BoltRevisionCacheL2) Delete(key IDAndRev) {
+	keyBytes := l2Key(key)
+	l2.lock.Lock()
+	defer l2.lock.Unlock()
+	l2.deleteKey_(keyBytes)
+	l2.setBytesStat()
+}
+
+// trimToCapacity_ removes the oldest-inserted entries until the store is back within maxBytes.
+// Must be called with l2.lock held.
+func (l2 *BoltRevisionCacheL2) trimToCapacity_() {
+	for l2.maxBytes > 0 && l2.size > l2.maxBytes && len(l2.order) > 0 {
+		oldest := l2.order[0]
+		l2.order = l2.order[1:]
+		l2.deleteKey_([]byte(oldest))
+	}
+}
+
+// deleteKey_ removes a single key from BoltDB and adjusts the tracked size.  Must be called with
+// l2.lock held.
+func (l2 *BoltRevisionCacheL2) deleteKey_(keyBytes []byte) {
+	_ = l2.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revCacheL2Bucket)
+		if data := bucket.Get(keyBytes); data != nil {
+			l2.size -= int64(len(data))
+		}
+		return bucket.Delete(keyBytes)
+	})
+}
+
+func (l2 *BoltRevisionCacheL2) setBytesStat() {
+	if l2.statsCache == nil {
+		return
+	}
+	bytesVar := new(expvar.Int)
+	l2.lock.Lock()
+	bytesVar.Set(l2.size)
+	l2.lock.Unlock()
+	l2.statsCache.Set(statRevCacheL2Bytes, bytesVar)
+}
+
+// Close releases the underlying BoltDB file handle.
+func (l2 *BoltRevisionCacheL2) Close() error {
+	return l2.db.Close()
+}
+
+func encodeGzipJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeGzipJSON(data []byte, v interface{}) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return json.NewDecoder(gz).Decode(v)
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestBoltL2(t *testing.T, maxBytes int64) *BoltRevisionCacheL2 {
+	t.Helper()
+	l2, err := NewBoltRevisionCacheL2(filepath.Join(t.TempDir(), "revcache.bolt"), maxBytes, nil)
+	if err != nil {
+		t.Fatalf("NewBoltRevisionCacheL2: %v", err)
+	}
+	t.Cleanup(func() { _ = l2.Close() })
+	return l2
+}
+
+// TestBoltRevisionCacheL2RoundTrip checks that a Put'd entry comes back from Get with matching
+// body/history and a non-zero writtenAt, and that a key never written is reported as not found.
+func TestBoltRevisionCacheL2RoundTrip(t *testing.T) {
+	l2 := openTestBoltL2(t, 0)
+	key := IDAndRev{DocID: "doc1", RevID: "1-abc"}
+
+	if _, _, _, _, _, _, found := l2.Get(key); found {
+		t.Fatalf("expected miss for a key never written")
+	}
+
+	l2.Put(key, DocumentRevision{RevID: key.RevID, Body: Body{BodyId: key.DocID, BodyRev: key.RevID}, History: Revisions{}})
+
+	body, _, _, _, _, writtenAt, found := l2.Get(key)
+	if !found {
+		t.Fatalf("expected hit after Put")
+	}
+	if body.BodyId != key.DocID {
+		t.Fatalf("expected round-tripped body to match, got %v", body)
+	}
+	if writtenAt.IsZero() {
+		t.Fatalf("expected writtenAt to be stamped on Put")
+	}
+
+	l2.Delete(key)
+	if _, _, _, _, _, _, found := l2.Get(key); found {
+		t.Fatalf("expected miss after Delete")
+	}
+}
+
+// TestBoltRevisionCacheL2FIFOTrim checks that once maxBytes is exceeded, the oldest-inserted
+// entries are evicted first, regardless of access pattern - this tier trims FIFO, not LRU.
+func TestBoltRevisionCacheL2FIFOTrim(t *testing.T) {
+	// Each payload is small and roughly the same size once gzipped, so capping at the size of a
+	// couple of entries forces earlier ones out as later ones are written.
+	l2 := openTestBoltL2(t, 0)
+	keys := make([]IDAndRev, 5)
+	for i := range keys {
+		keys[i] = IDAndRev{DocID: "doc", RevID: string(rune('1' + i)) + "-abc"}
+		l2.Put(keys[i], DocumentRevision{RevID: keys[i].RevID, Body: Body{BodyId: keys[i].DocID, BodyRev: keys[i].RevID}, History: Revisions{}})
+	}
+	l2.lock.Lock()
+	perEntry := l2.size / int64(len(keys))
+	l2.lock.Unlock()
+
+	trimmed := openTestBoltL2(t, perEntry*2)
+	for _, key := range keys {
+		trimmed.Put(key, DocumentRevision{RevID: key.RevID, Body: Body{BodyId: key.DocID, BodyRev: key.RevID}, History: Revisions{}})
+	}
+
+	if _, _, _, _, _, _, found := trimmed.Get(keys[0]); found {
+		t.Fatalf("expected oldest entry to have been trimmed")
+	}
+	if _, _, _, _, _, _, found := trimmed.Get(keys[len(keys)-1]); !found {
+		t.Fatalf("expected most recently written entry to survive trimming")
+	}
+}
+
+// TestBoltRevisionCacheL2PutReplacesExisting checks that re-Putting an already-resident key
+// replaces its order/size bookkeeping instead of appending a duplicate - a key that's evicted to
+// L2, reloaded into L1, then evicted again is a normal occurrence, not an edge case.
+func TestBoltRevisionCacheL2PutReplacesExisting(t *testing.T) {
+	l2 := openTestBoltL2(t, 0)
+	key := IDAndRev{DocID: "doc1", RevID: "1-abc"}
+
+	l2.Put(key, DocumentRevision{RevID: key.RevID, Body: Body{BodyId: key.DocID, BodyRev: key.RevID}, History: Revisions{}})
+	l2.lock.Lock()
+	firstSize := l2.size
+	l2.lock.Unlock()
+
+	l2.Put(key, DocumentRevision{RevID: key.RevID, Body: Body{BodyId: key.DocID, BodyRev: key.RevID}, History: Revisions{}})
+
+	l2.lock.Lock()
+	defer l2.lock.Unlock()
+	if l2.size != firstSize {
+		t.Fatalf("expected size to reflect a single entry after a re-Put, got %d want %d", l2.size, firstSize)
+	}
+	count := 0
+	for _, k := range l2.order {
+		if k == string(l2Key(key)) {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected exactly one order entry for a re-Put key, got %d", count)
+	}
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestShardForKeyDistribution checks that shardForKey is deterministic per key and spreads a
+// reasonably sized key set across more than one shard, which is the entire point of sharding - a
+// hash that routed everything to a single shard would silently defeat it while still compiling.
+func TestShardForKeyDistribution(t *testing.T) {
+	rc := NewShardedRevisionCache(1000, DefaultRevisionCacheShardCount, nil, nil)
+
+	key := IDAndRev{DocID: "doc1", RevID: "1-abc"}
+	first := rc.shardForKey(key)
+	for i := 0; i < 10; i++ {
+		if rc.shardForKey(key) != first {
+			t.Fatalf("shardForKey(%v) is not deterministic", key)
+		}
+	}
+
+	seen := map[*revisionCacheShard]bool{}
+	for i := 0; i < 200; i++ {
+		seen[rc.shardForKey(IDAndRev{DocID: fmt.Sprintf("doc%d", i), RevID: "1-abc"})] = true
+	}
+	if len(seen) < DefaultRevisionCacheShardCount/2 {
+		t.Fatalf("expected keys to spread across most of the %d shards, only hit %d", DefaultRevisionCacheShardCount, len(seen))
+	}
+}
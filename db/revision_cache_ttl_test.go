@@ -0,0 +1,74 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// countingLoader returns a RevisionCacheLoaderFunc that counts how many times it's invoked, so
+// tests can tell a genuine reload from a served-from-cache hit.
+func countingLoader(calls *int) RevisionCacheLoaderFunc {
+	return func(id IDAndRev) (Body, Revisions, base.Set, AttachmentsMeta, *time.Time, error) {
+		*calls++
+		return Body{BodyId: id.DocID, BodyRev: id.RevID}, Revisions{}, nil, nil, nil, nil
+	}
+}
+
+// TestRevisionCacheTTLExpiry checks that an entry older than the configured TTL is reloaded on the
+// next Get rather than served stale, via both the sweeper and the inline isExpired check on read.
+func TestRevisionCacheTTLExpiry(t *testing.T) {
+	var calls int
+	// A multiple of DefaultRevisionCacheShardCount so every shard gets equal, non-zero capacity
+	// regardless of which shard the test key happens to hash to.
+	cache := NewRevisionCacheWithTTL(2*DefaultRevisionCacheShardCount, 20*time.Millisecond, countingLoader(&calls), nil)
+	defer cache.Close()
+
+	if _, err := cache.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 load, got %d", calls)
+	}
+
+	// Still fresh: a second Get should be served from cache, not reload.
+	if _, err := cache.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected entry to still be cached, got %d loads", calls)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, err := cache.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected expired entry to be reloaded, got %d loads", calls)
+	}
+}
+
+// TestRevisionCacheTTLDisabled checks that a ttl <= 0 disables expiration entirely - no background
+// sweeper runs, and entries are served from cache indefinitely (bounded only by LRU capacity).
+func TestRevisionCacheTTLDisabled(t *testing.T) {
+	var calls int
+	cache := NewRevisionCacheWithTTL(2*DefaultRevisionCacheShardCount, 0, countingLoader(&calls), nil)
+	defer cache.Close()
+
+	if cache.stopSweep != nil {
+		t.Fatalf("expected no sweeper to be started for ttl <= 0")
+	}
+
+	if _, err := cache.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := cache.Get("doc1", "1-abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected entry to never expire, got %d loads", calls)
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Names of the expvar counters emitted by PreloadRevisions.
+const (
+	statRevCacheWarmupLoaded     = "rev_cache_warmup_loaded"
+	statRevCacheWarmupErrors     = "rev_cache_warmup_errors"
+	statRevCacheWarmupDurationMs = "rev_cache_warmup_duration_ms"
+)
+
+// DefaultRevisionCacheWarmupWorkers is the number of concurrent loaderFunc calls PreloadRevisions
+// makes, absent any other configuration.
+const DefaultRevisionCacheWarmupWorkers = 16
+
+// PreloadRevisions populates the cache with ids by concurrently calling through to the cache's
+// loaderFunc, using DefaultRevisionCacheWarmupWorkers concurrent loads. It's intended to be driven
+// by a changes/DCP feed consumer on startup, to avoid a cold-cache miss storm against the bucket
+// once client replication traffic resumes.
+//
+// ctx cancellation stops feeding new ids to the worker pool as soon as the feeder goroutine next
+// wakes, and no further loads are started once ids are exhausted; a load already handed to a worker
+// is allowed to finish. PreloadRevisions returns the first error encountered, if any, but doesn't
+// abort early on error - a failure to warm one revision shouldn't prevent warming the rest.
+//
+// This is the cache-side primitive only: the DatabaseContext-level hook that subscribes to the
+// DCP/changes feed, the warmup_channels config that picks which ids to pass in, and a cap on how
+// many revs to warm per startup are follow-up work and don't exist yet.
+func (rc *RevisionCache) PreloadRevisions(ctx context.Context, ids []IDAndRev) error {
+	return rc.PreloadRevisionsWithWorkers(ctx, ids, DefaultRevisionCacheWarmupWorkers)
+}
+
+// PreloadRevisionsWithWorkers is PreloadRevisions with the worker pool size under caller control,
+// for operators who want to trade warm-up speed against bucket load. workers <= 0 falls back to
+// DefaultRevisionCacheWarmupWorkers.
+func (rc *RevisionCache) PreloadRevisionsWithWorkers(ctx context.Context, ids []IDAndRev, workers int) error {
+	if workers <= 0 {
+		workers = DefaultRevisionCacheWarmupWorkers
+	}
+
+	start := time.Now()
+	defer func() {
+		rc.addStat(statRevCacheWarmupDurationMs, time.Since(start).Milliseconds())
+	}()
+
+	idChan := make(chan IDAndRev)
+	go func() {
+		defer close(idChan)
+		for _, id := range ids {
+			select {
+			case <-ctx.Done():
+				return
+			case idChan <- id:
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		firstErr error
+		errOnce  sync.Once
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range idChan {
+				if _, err := rc.Get(id.DocID, id.RevID); err != nil {
+					rc.addStat(statRevCacheWarmupErrors, 1)
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+				rc.addStat(statRevCacheWarmupLoaded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/couchbase/sync_gateway/base"
+)
+
+// TestPreloadRevisionsWithWorkersDefaultWorkerCount checks that workers <= 0 falls back to
+// DefaultRevisionCacheWarmupWorkers rather than leaving the pool empty, and that every id passed in
+// is loaded.
+func TestPreloadRevisionsWithWorkersDefaultWorkerCount(t *testing.T) {
+	var loaded int32
+	loader := func(id IDAndRev) (Body, Revisions, base.Set, AttachmentsMeta, *time.Time, error) {
+		atomic.AddInt32(&loaded, 1)
+		return Body{BodyId: id.DocID, BodyRev: id.RevID}, Revisions{}, nil, nil, nil, nil
+	}
+	rc := NewRevisionCache(1000, loader, nil)
+
+	ids := make([]IDAndRev, 100)
+	for i := range ids {
+		ids[i] = IDAndRev{DocID: fmt.Sprintf("doc%d", i), RevID: "1-abc"}
+	}
+
+	if err := rc.PreloadRevisionsWithWorkers(context.Background(), ids, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if int(loaded) != len(ids) {
+		t.Fatalf("expected all %d ids to be loaded, got %d", len(ids), loaded)
+	}
+}
+
+// TestPreloadRevisionsWithWorkersCancellation checks that cancelling ctx stops the feeder from
+// handing out further ids, rather than running the preload to completion regardless.
+func TestPreloadRevisionsWithWorkersCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var loaded int32
+	loader := func(id IDAndRev) (Body, Revisions, base.Set, AttachmentsMeta, *time.Time, error) {
+		if atomic.AddInt32(&loaded, 1) == 3 {
+			cancel()
+		}
+		return Body{BodyId: id.DocID, BodyRev: id.RevID}, Revisions{}, nil, nil, nil, nil
+	}
+	rc := NewRevisionCache(1000, loader, nil)
+
+	ids := make([]IDAndRev, 50)
+	for i := range ids {
+		ids[i] = IDAndRev{DocID: fmt.Sprintf("doc%d", i), RevID: "1-abc"}
+	}
+
+	// A single worker makes the feeder and loader strictly sequential, so cancellation reliably
+	// happens before every id has been fed in.
+	if err := rc.PreloadRevisionsWithWorkers(ctx, ids, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded >= int32(len(ids)) {
+		t.Fatalf("expected cancellation to stop the feeder before all %d ids were loaded, got %d", len(ids), loaded)
+	}
+}
+
+// TestPreloadRevisionsWithWorkersErrorAggregation checks that a loaderFunc error for one id is
+// surfaced as the returned error without preventing the rest of the ids from being warmed.
+func TestPreloadRevisionsWithWorkersErrorAggregation(t *testing.T) {
+	wantErr := errors.New("boom")
+	loader := func(id IDAndRev) (Body, Revisions, base.Set, AttachmentsMeta, *time.Time, error) {
+		if id.DocID == "bad" {
+			return Body{}, nil, nil, nil, nil, wantErr
+		}
+		return Body{BodyId: id.DocID, BodyRev: id.RevID}, Revisions{}, nil, nil, nil, nil
+	}
+	rc := NewRevisionCache(1000, loader, nil)
+
+	ids := []IDAndRev{
+		{DocID: "good1", RevID: "1-abc"},
+		{DocID: "bad", RevID: "1-abc"},
+		{DocID: "good2", RevID: "1-abc"},
+	}
+
+	if err := rc.PreloadRevisionsWithWorkers(context.Background(), ids, 4); err != wantErr {
+		t.Fatalf("expected the loader's error to be surfaced, got %v", err)
+	}
+
+	docRev, err := rc.GetCached("good1", "1-abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if docRev.RevID == "" {
+		t.Fatalf("expected good1 to have been warmed despite bad's failure")
+	}
+}